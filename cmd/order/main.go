@@ -3,31 +3,33 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/template/html/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/nats-io/nats.go"
 
+	"order/cqrs"
 	"order/domain"
+	"order/internal/config"
 	"order/internal/repositories"
 	"order/internal/server"
+	"order/internal/usecases"
+	"order/internal/validation"
 	"order/pkg/cache"
+	"order/pkg/lifecycle"
 	natsLocal "order/pkg/nats"
 )
 
-// OrderRepository чтобы не завязываться на конкретной реализации
-// объявляем интерфейс по работе с заказами тут
-type OrderRepository interface {
-	Create(ctx context.Context, order *domain.Order) (int64, error)
-}
-
 func main() {
 	if err := Main(); err != nil {
 		log.Fatal(err)
@@ -44,29 +46,24 @@ func Main() error {
 	// также у нас есть функции defer с Close() методами, которые закрывают все активные ресурсы
 	defer cancel()
 
-	// полуаем данные из переменных сред/окржения
-	// примеры можно посомтреть в .env файле проекта
-	o := opt{
-		host: os.Getenv("PG_HOST"),
-		user: os.Getenv("PG_USER"),
-		pass: os.Getenv("PG_PASS"),
-		port: os.Getenv("PG_PORT"),
-		name: os.Getenv("PG_NAME"),
+	// собираем типизированную конфигурацию из переменных окружения (и, опционально,
+	// CONFIG_PATH) - при отсутствующих или невалидных значениях падаем сразу со списком
+	// всех проблемных переменных, а не с первой попавшейся
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 
 	// подключаемся к базе данных Postgres
-	db, err := sqlx.Open("postgres", o.ConnectionString())
+	db, err := sqlx.Open("postgres", cfg.Postgres.ConnectionString())
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		_ = db.Close()
-	}()
-
-	// тут можно настроить параметры подключения к базе
-	db.SetMaxOpenConns(10)
-	db.SetMaxOpenConns(12)
+	// тут настраиваем параметры подключения к базе
+	db.SetMaxOpenConns(cfg.Postgres.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Postgres.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Postgres.ConnMaxLifetime)
 
 	// тут уже подключаем саму реализацию репозитория
 	repo, err := repositories.NewOrderRepository(ctx, db)
@@ -75,15 +72,37 @@ func Main() error {
 	}
 
 	// инициализиурем наш клиент Nats
-	natsClient, err := natsLocal.New(os.Getenv("NATS_URL"))
+	natsClient, err := natsLocal.New(cfg.NATS.URL,
+		nats.ReconnectWait(cfg.NATS.ReconnectWait),
+		nats.MaxReconnects(cfg.NATS.MaxReconnects),
+	)
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		// тут мы очищаем ненужные нам данные, подписчиков и т.п., обрываем соединение с Nats
-		_ = natsClient.Close()
-	}()
+	// inFlight считает команды/запросы, которые сейчас выполняются через шину, чтобы при
+	// остановке приложения можно было дождаться их завершения вместо того, чтобы обрывать
+	// их на середине
+	var inFlight sync.WaitGroup
+
+	// шина команд/запросов - единая точка, через которую проходят все use case'ы приложения,
+	// и единое место для сквозной функциональности (логирование, валидация, recovery)
+	bus := cqrs.NewBus(
+		cqrs.RecoveryMiddleware(),
+		cqrs.LoggingMiddleware(),
+		cqrs.ValidationMiddleware(),
+		cqrs.InFlightMiddleware(&inFlight),
+	)
+	cqrs.RegisterQuery[usecases.ListOrdersQuery, []domain.Order](bus, usecases.NewListOrdersHandler(repo))
+	cqrs.RegisterQuery[usecases.GetOrderQuery, domain.Order](bus, usecases.NewGetOrderHandler(repo, cache.NewInMemory(), cfg.Cache.TTL))
+	cqrs.RegisterCommandWithResult[usecases.CreateOrderCommand, int64](bus, usecases.NewCreateOrderHandler(repo))
+
+	// фоновый воркер забирает записи из order_outbox и публикует их нижестоящим потребителям
+	// в cfg.NATS.OutboxSubject - это дает at-least-once доставку между HTTP/Nats слоем приема
+	// заказов и базой, независимо от того, был ли заказ создан через create, publish или
+	// событие из Nats. Топик намеренно отличается от "test_topic", который слушает сам
+	// сервис, иначе каждый заказ republish-ился бы обратно во входящий топик
+	go repo.RunOutboxWorker(ctx, natsClient, cfg.NATS.OutboxSubject, time.Second)
 
 	// нужно, чтобы можно было выйти из приложения по команде
 	sig := make(chan os.Signal, 1)
@@ -91,12 +110,12 @@ func Main() error {
 
 	// подписываемся на топик в Nats-Streaming в отдельной горутине, чтобы нчиего не блокировать
 	go func() {
-		// подписываемся на токи test_topic
-		err = natsClient.Subscribe("test_topic", func(msg *nats.Msg) {
-			if err = handleEvent(ctx, repo, msg.Data); err != nil {
-				log.Println(err)
-			}
-		})
+		// подписываемся на токи test_topic; необработанные сообщения (после исчерпания
+		// повторов или при постоянной ошибке) уходят в test_topic.dlq вместо того, чтобы
+		// молча теряться
+		err = natsClient.Subscribe("test_topic", func(ctx context.Context, data []byte) error {
+			return handleEvent(ctx, bus, data)
+		}, natsLocal.WithDeadLetterSubject("test_topic.dlq"))
 		if err != nil {
 			log.Printf("failed to subscribe Nats-Streaming: %s\n", err)
 
@@ -107,23 +126,25 @@ func Main() error {
 	}()
 
 	// случаем tcp интерфейс
-	ln, err := net.Listen(fiber.NetworkTCP4, os.Getenv("HTTP_ADDRESS"))
+	ln, err := net.Listen(fiber.NetworkTCP4, cfg.HTTP.Address)
 	if err != nil {
 		return fmt.Errorf("failed to get http listener: %w", err)
 	}
 
-	// запускаем сервер в отдельной горутине
-	go func() {
-		handler := server.NewHandler(repo, cache.NewInMemory(), natsClient)
+	handler := server.NewHandler(bus)
 
-		app := fiber.New(fiber.Config{
-			Views:        html.New("./templates", ".html"),
-			ServerHeader: "Order Server",
-		})
+	app := fiber.New(fiber.Config{
+		Views:        html.New("./templates", ".html"),
+		ServerHeader: "Order Server",
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+	})
 
-		handler.MountRoutes(app)
+	handler.MountRoutes(app)
 
-		if err = app.Listener(ln); err != nil {
+	// запускаем сервер в отдельной горутине
+	go func() {
+		if err := app.Listener(ln); err != nil {
 			log.Printf("failed to start http server: %s\n", err)
 			sig <- syscall.SIGINT
 		}
@@ -134,18 +155,63 @@ func Main() error {
 	// или когда может возникнуть ошибка выше тогда мы сами посылаем сигнал на завершение
 	<-sig
 
-	return nil
+	return shutdown(cfg, app, natsClient, db, &inFlight)
 }
 
-func handleEvent(ctx context.Context, repo OrderRepository, data []byte) error {
+// shutdown останавливает компоненты приложения по очереди, в порядке, в котором они должны
+// уходить: сперва HTTP перестает принимать новые соединения, затем Nats перестает принимать
+// новые сообщения и дожидается уже запущенных, затем мы ждем завершения in-flight команд/
+// запросов, идущих через шину, и только после этого закрываем пул соединений с базой.
+// Каждый шаг ограничен своим собственным дедлайном, поэтому зависший компонент не мешает
+// остановке остальных - из возвращенной ошибки видно, какой именно компонент не уложился.
+func shutdown(cfg *config.Config, app *fiber.App, natsClient *natsLocal.Client, db *sqlx.DB, inFlight *sync.WaitGroup) error {
+	group := lifecycle.NewGroup()
+
+	group.Register("http", cfg.HTTP.ShutdownTimeout, func(_ context.Context) error {
+		return app.ShutdownWithTimeout(cfg.HTTP.ShutdownTimeout)
+	})
+
+	group.Register("nats", cfg.NATS.DrainTimeout, func(ctx context.Context) error {
+		return natsClient.Drain(ctx)
+	})
+
+	group.Register("in-flight handlers", cfg.ShutdownGrace, func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			inFlight.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	group.Register("database", cfg.Postgres.ShutdownTimeout, func(_ context.Context) error {
+		return db.Close()
+	})
+
+	return group.Shutdown(context.Background())
+}
+
+func handleEvent(ctx context.Context, bus *cqrs.Bus, data []byte) error {
 	request := &domain.Order{}
 	if err := json.Unmarshal(data, request); err != nil {
-		return fmt.Errorf("failed to unmarshal input json: %w", err)
+		// сообщение никогда не пройдет разбор само по себе - повторять его бессмысленно
+		return natsLocal.Permanent(fmt.Errorf("failed to unmarshal input json: %w", err))
 	}
 
-	_, err := repo.Create(ctx, request)
-	if err != nil {
-		return fmt.Errorf("failed to save data to database: %w", err)
+	if err := cqrs.Dispatch(ctx, bus, usecases.CreateOrderCommand{Order: *request}); err != nil {
+		var validationErr *validation.ValidationError
+		if errors.As(err, &validationErr) {
+			// этот заказ никогда не пройдет валидацию - повторять бессмысленно
+			return natsLocal.Permanent(fmt.Errorf("invalid order: %w", err))
+		}
+		// иначе это, как правило, временная ошибка записи в базу (недоступность Postgres и т.п.)
+		return natsLocal.Retryable(fmt.Errorf("failed to save data to database: %w", err))
 	}
 	return nil
 }
@@ -0,0 +1,152 @@
+// Package cqrs дает минимальную инфраструктуру для разделения команд (изменяющих состояние)
+// и запросов (читающих состояние) и диспетчеризации их в зарегистрированные обработчики
+// через единую шину в рамках одного процесса.
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Command маркерный интерфейс для команд - сообщений, изменяющих состояние системы
+type Command interface{}
+
+// Query маркерный интерфейс для запросов - сообщений, читающих состояние системы
+type Query interface{}
+
+// CommandHandler обрабатывает команду C, не возвращая результата
+type CommandHandler[C Command] interface {
+	Handle(ctx context.Context, cmd C) error
+}
+
+// CommandHandlerWithResult обрабатывает команду C и дополнительно возвращает результат R -
+// используется вместо CommandHandler, когда вызывающей стороне нужно что-то узнать о
+// произведенном изменении (например, сколько строк было затронуто), а не только факт ошибки
+type CommandHandlerWithResult[C Command, R any] interface {
+	Handle(ctx context.Context, cmd C) (R, error)
+}
+
+// QueryHandler обрабатывает запрос Q и возвращает результат R
+type QueryHandler[Q Query, R any] interface {
+	Handle(ctx context.Context, query Q) (R, error)
+}
+
+// HandlerFunc приведенный к общему виду обработчик, используемый внутри шины и в middleware
+type HandlerFunc func(ctx context.Context, msg interface{}) (interface{}, error)
+
+// Middleware оборачивает HandlerFunc, позволяя добавлять сквозную функциональность
+// (логирование, метрики, валидацию, recovery) в одном месте вместо каждого обработчика
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Validatable может быть реализован командой или запросом, чтобы ValidationMiddleware
+// проверяла его перед тем, как сообщение дойдет до обработчика
+type Validatable interface {
+	Validate() error
+}
+
+// Bus регистрирует обработчики по типу сообщения и диспетчеризирует команды/запросы в них,
+// пропуская каждое сообщение через цепочку middleware
+type Bus struct {
+	handlers    map[reflect.Type]HandlerFunc
+	middlewares []Middleware
+}
+
+func NewBus(mw ...Middleware) *Bus {
+	return &Bus{
+		handlers:    make(map[reflect.Type]HandlerFunc),
+		middlewares: mw,
+	}
+}
+
+// Use добавляет middleware в конец цепочки; порядок добавления - это порядок выполнения
+// от внешнего к внутреннему, т.е. последний добавленный middleware ближе всего к обработчику
+func (b *Bus) Use(mw Middleware) {
+	b.middlewares = append(b.middlewares, mw)
+}
+
+func (b *Bus) register(msgType reflect.Type, h HandlerFunc) {
+	if _, exists := b.handlers[msgType]; exists {
+		panic(fmt.Sprintf("cqrs: handler already registered for %s", msgType))
+	}
+	b.handlers[msgType] = h
+}
+
+func (b *Bus) dispatch(ctx context.Context, msg interface{}) (interface{}, error) {
+	msgType := reflect.TypeOf(msg)
+
+	h, ok := b.handlers[msgType]
+	if !ok {
+		return nil, fmt.Errorf("cqrs: no handler registered for %s", msgType)
+	}
+
+	// применяем middleware в обратном порядке, чтобы первый добавленный выполнился первым
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		h = b.middlewares[i](h)
+	}
+
+	return h(ctx, msg)
+}
+
+// RegisterCommand регистрирует обработчик для команд типа C
+func RegisterCommand[C Command](b *Bus, h CommandHandler[C]) {
+	var zero C
+	b.register(reflect.TypeOf(zero), func(ctx context.Context, msg interface{}) (interface{}, error) {
+		cmd, ok := msg.(C)
+		if !ok {
+			return nil, fmt.Errorf("cqrs: unexpected message type %T", msg)
+		}
+		return nil, h.Handle(ctx, cmd)
+	})
+}
+
+// RegisterQuery регистрирует обработчик для запросов типа Q, возвращающих результат R
+func RegisterQuery[Q Query, R any](b *Bus, h QueryHandler[Q, R]) {
+	var zero Q
+	b.register(reflect.TypeOf(zero), func(ctx context.Context, msg interface{}) (interface{}, error) {
+		query, ok := msg.(Q)
+		if !ok {
+			return nil, fmt.Errorf("cqrs: unexpected message type %T", msg)
+		}
+		return h.Handle(ctx, query)
+	})
+}
+
+// RegisterCommandWithResult регистрирует обработчик для команд типа C, возвращающих результат R
+func RegisterCommandWithResult[C Command, R any](b *Bus, h CommandHandlerWithResult[C, R]) {
+	var zero C
+	b.register(reflect.TypeOf(zero), func(ctx context.Context, msg interface{}) (interface{}, error) {
+		cmd, ok := msg.(C)
+		if !ok {
+			return nil, fmt.Errorf("cqrs: unexpected message type %T", msg)
+		}
+		return h.Handle(ctx, cmd)
+	})
+}
+
+// Dispatch отправляет команду C в шину и ждет ее выполнения
+func Dispatch[C Command](ctx context.Context, b *Bus, cmd C) error {
+	_, err := b.dispatch(ctx, cmd)
+	return err
+}
+
+// DispatchWithResult отправляет команду C в шину и возвращает результат R, произведенный
+// CommandHandlerWithResult
+func DispatchWithResult[C Command, R any](ctx context.Context, b *Bus, cmd C) (R, error) {
+	res, err := b.dispatch(ctx, cmd)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+	return res.(R), nil
+}
+
+// Ask отправляет запрос Q в шину и возвращает результат R
+func Ask[Q Query, R any](ctx context.Context, b *Bus, query Q) (R, error) {
+	res, err := b.dispatch(ctx, query)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+	return res.(R), nil
+}
@@ -0,0 +1,86 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// LoggingMiddleware логирует тип сообщения, длительность обработки и ошибку, если она была
+func LoggingMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg interface{}) (interface{}, error) {
+			start := time.Now()
+			res, err := next(ctx, msg)
+			if err != nil {
+				log.Printf("cqrs: %T failed in %s: %s", msg, time.Since(start), err)
+			} else {
+				log.Printf("cqrs: %T handled in %s", msg, time.Since(start))
+			}
+			return res, err
+		}
+	}
+}
+
+// ValidationMiddleware вызывает Validate() у сообщений, которые реализуют Validatable,
+// и не пропускает их дальше по цепочке при ошибке валидации
+func ValidationMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg interface{}) (interface{}, error) {
+			if v, ok := msg.(Validatable); ok {
+				if err := v.Validate(); err != nil {
+					return nil, err
+				}
+			}
+			return next(ctx, msg)
+		}
+	}
+}
+
+// MetricsCollector получает уведомление о каждом выполненном сообщении;
+// конкретная реализация (prometheus и т.п.) подключается снаружи пакета
+type MetricsCollector interface {
+	Observe(msgType string, duration time.Duration, success bool)
+}
+
+// MetricsMiddleware сообщает collector-у длительность и результат обработки каждого сообщения
+func MetricsMiddleware(collector MetricsCollector) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg interface{}) (interface{}, error) {
+			start := time.Now()
+			res, err := next(ctx, msg)
+			collector.Observe(fmt.Sprintf("%T", msg), time.Since(start), err == nil)
+			return res, err
+		}
+	}
+}
+
+// InFlightMiddleware отмечает каждое диспетчеризированное сообщение в wg, пока оно не
+// обработано, чтобы при остановке приложения можно было дождаться завершения уже начатых
+// команд/запросов вместо того, чтобы обрывать их на середине
+func InFlightMiddleware(wg *sync.WaitGroup) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg interface{}) (interface{}, error) {
+			wg.Add(1)
+			defer wg.Done()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// RecoveryMiddleware перехватывает панику в обработчике и превращает ее в ошибку,
+// чтобы паника в одном обработчике не могла уронить вызывающую горутину (например, подписчика Nats)
+func RecoveryMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg interface{}) (res interface{}, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("cqrs: recovered from panic while handling %T: %v", msg, r)
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
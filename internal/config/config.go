@@ -0,0 +1,88 @@
+// Package config собирает типизированную конфигурацию приложения из переменных окружения
+// (и, опционально, YAML файла) в одном месте, вместо разбросанных по всему коду os.Getenv
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/caarlos0/env/v9"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Postgres Postgres
+	NATS     NATS
+	HTTP     HTTP
+	Cache    Cache
+
+	// ShutdownGrace время, отведенное на завершение in-flight обработчиков команд/запросов
+	// (в т.ч. обрабатываемых сообщений Nats) при остановке приложения
+	ShutdownGrace time.Duration `yaml:"shutdown_grace" env:"SHUTDOWN_GRACE" envDefault:"15s"`
+}
+
+type Postgres struct {
+	Host            string        `yaml:"host" env:"PG_HOST,required"`
+	User            string        `yaml:"user" env:"PG_USER,required"`
+	Pass            string        `yaml:"pass" env:"PG_PASS,required"`
+	Port            string        `yaml:"port" env:"PG_PORT" envDefault:"5432"`
+	Name            string        `yaml:"name" env:"PG_NAME,required"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"PG_MAX_OPEN_CONNS" envDefault:"10"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"PG_MAX_IDLE_CONNS" envDefault:"5"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"PG_CONN_MAX_LIFETIME" envDefault:"1h"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"PG_SHUTDOWN_TIMEOUT" envDefault:"5s"`
+}
+
+// ConnectionString собирает DSN для sqlx.Open("postgres", ...)
+func (p Postgres) ConnectionString() string {
+	return fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s sslmode=disable",
+		p.User, p.Pass, p.Host, p.Port, p.Name)
+}
+
+type NATS struct {
+	URL           string        `yaml:"url" env:"NATS_URL,required"`
+	ReconnectWait time.Duration `yaml:"reconnect_wait" env:"NATS_RECONNECT_WAIT" envDefault:"2s"`
+	MaxReconnects int           `yaml:"max_reconnects" env:"NATS_MAX_RECONNECTS" envDefault:"60"`
+	DrainTimeout  time.Duration `yaml:"drain_timeout" env:"NATS_DRAIN_TIMEOUT" envDefault:"10s"`
+
+	// OutboxSubject топик, куда фоновый воркер outbox-а публикует обработанные заказы для
+	// нижестоящих потребителей - намеренно отличается от топика приема заказов (ingest),
+	// чтобы воркер не публиковал заказы обратно в тот же топик, который сам же и слушает
+	OutboxSubject string `yaml:"outbox_subject" env:"NATS_OUTBOX_SUBJECT" envDefault:"orders.created"`
+}
+
+type HTTP struct {
+	Address         string        `yaml:"address" env:"HTTP_ADDRESS,required"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" envDefault:"5s"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" envDefault:"10s"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" envDefault:"5s"`
+}
+
+type Cache struct {
+	TTL time.Duration `yaml:"ttl" env:"CACHE_TTL" envDefault:"1h"`
+}
+
+// Load собирает Config: сперва значения по умолчанию из YAML файла, путь к которому задан
+// в CONFIG_PATH (если переменная не задана, этот шаг пропускается), а затем поверх них -
+// переменные окружения. Ошибка включает в себя все невалидные/отсутствующие переменные сразу,
+// а не только первую найденную.
+func Load() (*Config, error) {
+	cfg := &Config{}
+
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to read %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: failed to parse %s: %w", path, err)
+		}
+	}
+
+	if err := env.Parse(cfg); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	return cfg, nil
+}
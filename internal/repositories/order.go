@@ -2,8 +2,11 @@ package repositories
 
 import (
 	"context"
+	"encoding/json"
+	"log"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/jmoiron/sqlx"
 
@@ -34,7 +37,29 @@ func (o *OrderRepository) migrate(ctx context.Context) error {
 );
 
 create unique index if not exists uq_order_uid
-    on public.order (order_uid);`
+    on public.order (order_uid);
+
+create table if not exists public.order_outbox
+(
+    id         bigserial primary key,
+    order_uid  text not null,
+    payload    jsonb not null,
+    status     text not null default 'pending',
+    attempts   int not null default 0,
+    last_error text,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists idx_order_outbox_pending
+    on public.order_outbox (id)
+    where status = 'pending';
+
+alter table public.order_outbox
+    add column if not exists claimed_at timestamptz;
+
+create index if not exists idx_order_outbox_processing_claimed_at
+    on public.order_outbox (claimed_at)
+    where status = 'processing';`
 
 	if _, err := o.db.ExecContext(ctx, query); err != nil {
 		return err
@@ -42,13 +67,24 @@ create unique index if not exists uq_order_uid
 	return nil
 }
 
+// Create сохраняет заказ и строку исходящего outbox в одной транзакции: сперва заказ (идемпотентно -
+// повтор с тем же order_uid не приводит к ошибке и не создает новую outbox-запись, то есть
+// отправку дубликата), затем outbox-запись, которую позже заберет ProcessOutbox
 func (o *OrderRepository) Create(ctx context.Context, order *domain.Order) (int64, error) {
-	const query = `INSERT INTO public.order (order_uid, data) VALUES (:id,:data)`
-	result, err := o.db.NamedExecContext(ctx, query, map[string]interface{}{
-		"id":   order.OrderUID,
-		"data": order,
-	})
+	tx, err := o.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
+	result, err := tx.NamedExecContext(ctx,
+		`INSERT INTO public.order (order_uid, data) VALUES (:id, :data) ON CONFLICT (order_uid) DO NOTHING`,
+		map[string]interface{}{
+			"id":   order.OrderUID,
+			"data": order,
+		})
 	if err != nil {
 		return 0, err
 	}
@@ -59,7 +95,24 @@ func (o *OrderRepository) Create(ctx context.Context, order *domain.Order) (int6
 		return 0, err
 	}
 
-	return affected, nil
+	// order_uid уже существует - это повтор одного и того же заказа, в outbox его дублировать не нужно
+	if affected == 0 {
+		return 0, tx.Commit()
+	}
+
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO public.order_outbox (order_uid, payload) VALUES ($1, $2)`,
+		order.OrderUID, payload,
+	); err != nil {
+		return 0, err
+	}
+
+	return affected, tx.Commit()
 }
 
 func (o *OrderRepository) Get(ctx context.Context, id string) (domain.Order, error) {
@@ -92,3 +145,130 @@ func (o *OrderRepository) List(ctx context.Context) ([]domain.Order, error) {
 
 	return orders, nil
 }
+
+// Publisher - это все, что нужно ProcessOutbox, чтобы отправить сообщение дальше,
+// реализуется, например, pkg/nats.Client
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+type outboxRow struct {
+	ID       int64  `db:"id"`
+	OrderUID string `db:"order_uid"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+}
+
+// maxOutboxAttempts количество попыток публикации, после которого запись считается
+// окончательно не доставленной и больше не выбирается воркером
+const maxOutboxAttempts = 5
+
+// outboxClaimStaleAfter если запись висит в 'processing' дольше этого времени, воркер считает,
+// что предыдущая попытка (скорее всего, оборванная рестартом/остановкой процесса между
+// claimPending и записью финального статуса) не завершилась, и забирает запись снова
+const outboxClaimStaleAfter = time.Minute
+
+// ProcessOutbox забирает пачку необработанных outbox-записей и публикует каждую в subject.
+// Блокировка строк (FOR UPDATE SKIP LOCKED, чтобы несколько воркеров не отправили одно и то же
+// сообщение дважды) удерживается только на время их пометки как "processing" в claimPending -
+// сами вызовы Publish идут уже без открытой транзакции, чтобы медленный или недоступный
+// downstream не держал лок на базе. Запись, исчерпавшая maxOutboxAttempts попыток, помечается
+// как "failed" и больше не выбирается. Возвращает число успешно опубликованных записей.
+func (o *OrderRepository) ProcessOutbox(ctx context.Context, publisher Publisher, subject string) (int, error) {
+	pending, err := o.claimPending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, row := range pending {
+		if pubErr := publisher.Publish(subject, row.Payload); pubErr != nil {
+			if err := o.recordFailure(ctx, row, pubErr); err != nil {
+				return processed, err
+			}
+			continue
+		}
+
+		if _, err := o.db.ExecContext(ctx,
+			`UPDATE public.order_outbox SET status = 'done' WHERE id = $1`, row.ID); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// claimPending выбирает пачку записей со статусом 'pending', а также зависшие в 'processing'
+// дольше outboxClaimStaleAfter (см. комментарий к константе - это случай, когда предыдущая
+// попытка оборвалась между claimPending и записью финального статуса, например при рестарте
+// воркера посреди обработки пачки), блокируя их FOR UPDATE SKIP LOCKED, чтобы несколько
+// воркеров не забрали одну и ту же запись, и сразу переводит их в 'processing' в той же
+// короткой транзакции, отпуская лок до того, как начнутся сетевые вызовы Publish
+func (o *OrderRepository) claimPending(ctx context.Context) ([]outboxRow, error) {
+	tx, err := o.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	staleBefore := time.Now().Add(-outboxClaimStaleAfter)
+
+	var pending []outboxRow
+	if err := tx.SelectContext(ctx, &pending,
+		`SELECT id, order_uid, payload, attempts FROM public.order_outbox
+		 WHERE status = 'pending' OR (status = 'processing' AND claimed_at < $1)
+		 ORDER BY id FOR UPDATE SKIP LOCKED LIMIT 50`, staleBefore); err != nil {
+		return nil, err
+	}
+
+	if len(pending) == 0 {
+		return nil, tx.Commit()
+	}
+
+	ids := make([]int64, len(pending))
+	for i, row := range pending {
+		ids[i] = row.ID
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE public.order_outbox SET status = 'processing', claimed_at = now() WHERE id = ANY($1)`,
+		pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	return pending, tx.Commit()
+}
+
+// recordFailure увеличивает счетчик попыток и возвращает запись либо обратно в 'pending'
+// для следующего тика, либо, если попытки исчерпаны, в терминальный статус 'failed'
+func (o *OrderRepository) recordFailure(ctx context.Context, row outboxRow, cause error) error {
+	attempts := row.Attempts + 1
+	status := "pending"
+	if attempts >= maxOutboxAttempts {
+		status = "failed"
+	}
+
+	_, err := o.db.ExecContext(ctx,
+		`UPDATE public.order_outbox SET status = $2, attempts = $3, last_error = $4 WHERE id = $1`,
+		row.ID, status, attempts, cause.Error())
+	return err
+}
+
+// RunOutboxWorker периодически вызывает ProcessOutbox, пока ctx не будет отменен
+func (o *OrderRepository) RunOutboxWorker(ctx context.Context, publisher Publisher, subject string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := o.ProcessOutbox(ctx, publisher, subject); err != nil {
+				log.Printf("outbox: failed to process pending orders: %s", err)
+			}
+		}
+	}
+}
@@ -1,55 +1,38 @@
 package server
 
 import (
-	"context"
-	"encoding/json"
-	"time"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
 
+	"order/cqrs"
 	"order/domain"
-	"order/pkg/nats"
+	"order/internal/usecases"
+	"order/internal/validation"
 )
 
-type Cache interface {
-	Set(ctx context.Context, key string, value domain.Order, ttl time.Duration) error
-	Get(ctx context.Context, key string) (domain.Order, bool, error)
-	Has(_ context.Context, key string) bool
-}
-
-type OrderRepository interface {
-	Create(ctx context.Context, order *domain.Order) (int64, error)
-	Get(ctx context.Context, id string) (domain.Order, error)
-	List(ctx context.Context) ([]domain.Order, error)
-}
-
-// Handler является некоторой оберткой над http интерфейсом, инкапсулирующий
-// внутри себя логику приема запросов от внешнего мира.
-// В целом можно сделать, используя, CQRS, но у нас только одна ручка создания записи Create.
-//
-// Вот как бы это выглядело в CQRS:
-//  - handler принимал бы querieries и commands
-//  - listQuerier(ctx, args listArgs)
-//  - getQuerier(ctx, args getArgs)
-//  - createCommandHandler(ctx, cmd createCommand)
+// Handler является тонкой оберткой над http интерфейсом: он только разбирает запрос,
+// диспетчеризирует соответствующую команду/запрос через шину cqrs.Bus и рендерит ответ.
+// Вся бизнес-логика (cache-aside для get, запись в репозиторий и outbox для create/publish
+// и т.п.) живет в обработчиках пакета usecases, а сквозные заботы (логирование, валидация,
+// recovery, учет in-flight) настраиваются один раз на самой шине - см. main.Main.
 type Handler struct {
-	orderRepository OrderRepository
-	cache           Cache
+	bus *cqrs.Bus
+}
 
-	nats *nats.Client
+func NewHandler(bus *cqrs.Bus) *Handler {
+	return &Handler{bus: bus}
 }
 
-func NewHandler(
-	orderRepository OrderRepository,
-	cache Cache,
-	nats *nats.Client,
-) *Handler {
-	h := &Handler{
-		orderRepository: orderRepository,
-		cache:           cache,
-		nats:            nats,
+// respondErr отображает ошибку шины в http ответ: невалидный заказ - это ошибка клиента,
+// поэтому *validation.ValidationError отдается как 400 с построчным списком нарушений, а не
+// падает в generic error handler Fiber-а, как любая другая (как правило, серверная) ошибка
+func respondErr(ctx *fiber.Ctx, err error) error {
+	var validationErr *validation.ValidationError
+	if errors.As(err, &validationErr) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(validationErr.Fields)
 	}
-	return h
+	return err
 }
 
 func (h *Handler) MountRoutes(app *fiber.App) {
@@ -68,7 +51,7 @@ func (h *Handler) MountRoutes(app *fiber.App) {
 // list ручка отображает страничку с записами
 func (h *Handler) list(ctx *fiber.Ctx) error {
 	// сюда также можно добавить пагинацию в виде limit & offset
-	all, err := h.orderRepository.List(ctx.Context())
+	all, err := cqrs.Ask[usecases.ListOrdersQuery, []domain.Order](ctx.Context(), h.bus, usecases.ListOrdersQuery{})
 	if err != nil {
 		return err
 	}
@@ -79,7 +62,7 @@ func (h *Handler) list(ctx *fiber.Ctx) error {
 
 // listJSON ручка получения списка записей в формате JSON
 func (h *Handler) listJSON(ctx *fiber.Ctx) error {
-	all, err := h.orderRepository.List(ctx.Context())
+	all, err := cqrs.Ask[usecases.ListOrdersQuery, []domain.Order](ctx.Context(), h.bus, usecases.ListOrdersQuery{})
 	if err != nil {
 		return err
 	}
@@ -96,13 +79,11 @@ func (h *Handler) create(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	// далее сохраняем запись в базу данных
-	affected, err := h.orderRepository.Create(ctx.Context(), request)
+	affected, err := cqrs.DispatchWithResult[usecases.CreateOrderCommand, int64](ctx.Context(), h.bus, usecases.CreateOrderCommand{Order: *request})
 	if err != nil {
-		return err
+		return respondErr(ctx, err)
 	}
 
-	// возвращаем клиенту ответ, сколько строк было сохранено
 	return ctx.JSON(map[string]interface{}{
 		"rows_affected": affected,
 	})
@@ -117,47 +98,29 @@ func (h *Handler) get(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// если в кеше есть значение, то сразу же возвращаем его,
-	// без необходимости читать из базы
-	if h.cache.Has(ctx.Context(), key) {
-		order, _, _ := h.cache.Get(ctx.Context(), key)
-		return ctx.JSON(map[string]interface{}{
-			"order": order,
-		})
-	}
-
-	// иначе, если данных в кеше не оказалось, получаем запись из базы по идентификатору
-	order, err := h.orderRepository.Get(ctx.Context(), key)
+	order, err := cqrs.Ask[usecases.GetOrderQuery, domain.Order](ctx.Context(), h.bus, usecases.GetOrderQuery{ID: key})
 	if err != nil {
 		return err
 	}
 
-	// далее сохраняем в кеше на один час (можно настроить)
-	_ = h.cache.Set(ctx.Context(), key, order, time.Hour)
-
-	// и возвращаем, только что сформированный ответ клиенту
 	return ctx.JSON(map[string]interface{}{
 		"order": order,
 	})
 }
 
-// publish данная ручка позволяет сохранять запись в базе не на прямую, а через очередь сообщений
-// в нашем случае это Nats-Streaming
-// эта ручка просто иммитация обычного shell скрипта, который бы отправлял данные
-// на прямую, непосредственно сервер Nats
+// publish данная ручка позволяет сохранять запись не напрямую, а имитируя путь события из
+// Nats-Streaming: заказ проходит ту же валидацию и тот же транзакционный outbox, что и
+// CreateOrderHandler, откуда его позже заберет и опубликует в Nats фоновый воркер outbox-а.
+// За счет идемпотентности записи по order_uid повторный вызов publish с тем же заказом
+// (например, при ретрае клиента) не создает дубликат в очереди.
 func (h *Handler) publish(ctx *fiber.Ctx) error {
 	request := &domain.Order{}
 	if err := ctx.BodyParser(request); err != nil {
 		return err
 	}
 
-	bytes, err := json.Marshal(request)
-	if err != nil {
-		return err
-	}
-
-	if err = h.nats.Publish("test_topic", bytes); err != nil {
-		return err
+	if err := cqrs.Dispatch(ctx.Context(), h.bus, usecases.CreateOrderCommand{Order: *request}); err != nil {
+		return respondErr(ctx, err)
 	}
 
 	return ctx.JSON(map[string]interface{}{
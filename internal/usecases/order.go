@@ -0,0 +1,95 @@
+// Package usecases содержит обработчики команд и запросов над сущностью Order,
+// вызываемые через шину cqrs.Bus вместо того, чтобы жить внутри http хендлера
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"order/domain"
+	"order/internal/validation"
+)
+
+type OrderRepository interface {
+	Create(ctx context.Context, order *domain.Order) (int64, error)
+	Get(ctx context.Context, id string) (domain.Order, error)
+	List(ctx context.Context) ([]domain.Order, error)
+}
+
+type Cache interface {
+	Set(ctx context.Context, key string, value domain.Order, ttl time.Duration) error
+	Get(ctx context.Context, key string) (domain.Order, bool, error)
+	Has(ctx context.Context, key string) bool
+}
+
+// ListOrdersQuery запрашивает все заказы
+type ListOrdersQuery struct{}
+
+type ListOrdersHandler struct {
+	repo OrderRepository
+}
+
+func NewListOrdersHandler(repo OrderRepository) *ListOrdersHandler {
+	return &ListOrdersHandler{repo: repo}
+}
+
+func (h *ListOrdersHandler) Handle(ctx context.Context, _ ListOrdersQuery) ([]domain.Order, error) {
+	return h.repo.List(ctx)
+}
+
+// GetOrderQuery запрашивает один заказ по идентификатору
+type GetOrderQuery struct {
+	ID string
+}
+
+type GetOrderHandler struct {
+	repo     OrderRepository
+	cache    Cache
+	cacheTTL time.Duration
+}
+
+func NewGetOrderHandler(repo OrderRepository, cache Cache, cacheTTL time.Duration) *GetOrderHandler {
+	return &GetOrderHandler{repo: repo, cache: cache, cacheTTL: cacheTTL}
+}
+
+// Handle реализует cache-aside: сперва смотрим в кеш и только при промахе идем в базу
+func (h *GetOrderHandler) Handle(ctx context.Context, query GetOrderQuery) (domain.Order, error) {
+	if h.cache.Has(ctx, query.ID) {
+		order, _, _ := h.cache.Get(ctx, query.ID)
+		return order, nil
+	}
+
+	order, err := h.repo.Get(ctx, query.ID)
+	if err != nil {
+		return domain.Order{}, err
+	}
+
+	_ = h.cache.Set(ctx, query.ID, order, h.cacheTTL)
+
+	return order, nil
+}
+
+// CreateOrderCommand сохраняет новый заказ
+type CreateOrderCommand struct {
+	Order domain.Order
+}
+
+// Validate делает CreateOrderCommand cqrs.Validatable, так что cqrs.ValidationMiddleware
+// отклонит невалидный заказ еще до того, как он дойдет до CreateOrderHandler и базы
+func (c CreateOrderCommand) Validate() error {
+	return validation.ValidateOrder(&c.Order)
+}
+
+type CreateOrderHandler struct {
+	repo OrderRepository
+}
+
+func NewCreateOrderHandler(repo OrderRepository) *CreateOrderHandler {
+	return &CreateOrderHandler{repo: repo}
+}
+
+// Handle возвращает число затронутых строк: 0, если order_uid уже существовал и запись была
+// повтором (см. OrderRepository.Create), иначе 1
+func (h *CreateOrderHandler) Handle(ctx context.Context, cmd CreateOrderCommand) (int64, error) {
+	return h.repo.Create(ctx, &cmd.Order)
+}
@@ -0,0 +1,99 @@
+// Package validation содержит правила, которым должен соответствовать domain.Order перед
+// тем, как он попадет в базу - неважно, пришел он через HTTP или через Nats
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"order/domain"
+)
+
+// orderUIDPattern order_uid используется как первичный ключ в varchar(19),
+// поэтому допустимы только буквы, цифры, дефис и подчеркивание
+var orderUIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// emailPattern намеренно простая проверка формата, без претензии на полное соответствие RFC 5322
+var emailPattern = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+// FieldError одна невалидная деталь заказа
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) String() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError содержит все найденные нарушения валидации одного заказа
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.String()
+	}
+	return fmt.Sprintf("order validation failed: %s", strings.Join(parts, "; "))
+}
+
+// ValidateOrder проверяет order на обязательные поля, ограничения базы (например,
+// order_uid varchar(19)) и базовую согласованность данных, прежде чем он будет записан в базу
+func ValidateOrder(order *domain.Order) error {
+	var fields []FieldError
+
+	add := func(field, message string) {
+		fields = append(fields, FieldError{Field: field, Message: message})
+	}
+
+	switch {
+	case order.OrderUID == "":
+		add("order_uid", "must not be empty")
+	case len(order.OrderUID) > 19:
+		add("order_uid", "must not be longer than 19 characters")
+	case !orderUIDPattern.MatchString(order.OrderUID):
+		add("order_uid", "must contain only letters, digits, '-' and '_'")
+	}
+
+	if order.TrackNumber == "" {
+		add("track_number", "must not be empty")
+	}
+
+	if order.Delivery.Email != "" && !emailPattern.MatchString(order.Delivery.Email) {
+		add("delivery.email", "must be a valid email address")
+	}
+
+	if order.Payment.Amount < 0 {
+		add("payment.amount", "must not be negative")
+	}
+	if order.Payment.DeliveryCost < 0 {
+		add("payment.delivery_cost", "must not be negative")
+	}
+	if order.Payment.GoodsTotal < 0 {
+		add("payment.goods_total", "must not be negative")
+	}
+
+	if len(order.Items) == 0 {
+		add("items", "must contain at least one item")
+	}
+	for i, item := range order.Items {
+		if item.Price < 0 {
+			add(fmt.Sprintf("items[%d].price", i), "must not be negative")
+		}
+		if item.TotalPrice < 0 {
+			add(fmt.Sprintf("items[%d].total_price", i), "must not be negative")
+		}
+	}
+
+	if order.DateCreated.IsZero() {
+		add("date_created", "must not be empty")
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
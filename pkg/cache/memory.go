@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"order/domain"
+)
+
+// entry хранит значение вместе с моментом времени, когда оно протухнет
+type entry struct {
+	value   domain.Order
+	expires time.Time
+}
+
+// InMemory простейшая реализация кеша поверх map с защитой через мьютекс,
+// без очистки устаревших записей - протухшие записи не отдаются при чтении, но остаются
+// в map, пока их не перезапишет Set
+type InMemory struct {
+	mu   sync.RWMutex
+	data map[string]entry
+}
+
+func NewInMemory() *InMemory {
+	return &InMemory{data: make(map[string]entry)}
+}
+
+func (c *InMemory) Set(_ context.Context, key string, value domain.Order, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data[key] = entry{value: value, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (c *InMemory) Get(_ context.Context, key string) (domain.Order, bool, error) {
+	c.mu.RLock()
+	e, ok := c.data[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(e.expires) {
+		return domain.Order{}, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (c *InMemory) Has(_ context.Context, key string) bool {
+	c.mu.RLock()
+	e, ok := c.data[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+	return time.Now().Before(e.expires)
+}
@@ -0,0 +1,76 @@
+// Package lifecycle помогает корректно завершать работу приложения: каждый компонент
+// (HTTP сервер, подписка Nats, пул соединений с базой и т.п.) регистрируется со своим
+// собственным дедлайном, и Shutdown гарантирует, что зависший компонент не заблокирует
+// остановку остальных - по истечении дедлайна его закрытие просто помечается как timeout
+// и группа переходит к следующему компоненту.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Closer останавливает один компонент приложения; ctx ограничен дедлайном,
+// зарегистрированным вместе с этим closer-ом через Group.Register
+type Closer func(ctx context.Context) error
+
+type component struct {
+	name     string
+	deadline time.Duration
+	closer   Closer
+}
+
+// Group упорядоченный набор компонентов, которые нужно остановить при завершении работы
+// приложения. Порядок регистрации - это порядок остановки.
+type Group struct {
+	components []component
+}
+
+func NewGroup() *Group {
+	return &Group{}
+}
+
+// Register добавляет компонент в группу: name используется в логах/ошибках, deadline -
+// максимальное время, отведенное closer-у на завершение.
+func (g *Group) Register(name string, deadline time.Duration, closer Closer) {
+	g.components = append(g.components, component{name: name, deadline: deadline, closer: closer})
+}
+
+// Shutdown останавливает все зарегистрированные компоненты по очереди, в порядке
+// регистрации, каждый со своим дедлайном. Компонент, не уложившийся в дедлайн, не
+// блокирует остановку следующих - ошибка таймаута просто добавляется в итоговый результат,
+// так что по возвращенной ошибке видно, какой именно компонент (HTTP, Nats, БД) удержал
+// завершение работы.
+func (g *Group) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	for _, c := range g.components {
+		if err := g.shutdownOne(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (g *Group) shutdownOne(parent context.Context, c component) error {
+	ctx, cancel := context.WithTimeout(parent, c.deadline)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.closer(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: did not shut down within %s", c.name, c.deadline)
+	}
+}
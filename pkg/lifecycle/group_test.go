@@ -0,0 +1,63 @@
+package lifecycle
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGroup_Shutdown_SlowComponentTimesOutButOthersStillRun(t *testing.T) {
+	g := NewGroup()
+
+	var ranAfterTimeout bool
+
+	g.Register("slow", 20*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		// имитируем зависший closer, который не укладывается в свой дедлайн
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+
+	g.Register("fast", time.Second, func(_ context.Context) error {
+		ranAfterTimeout = true
+		return nil
+	})
+
+	err := g.Shutdown(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for the component that timed out")
+	}
+
+	want := "slow: did not shut down within 20ms"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error to contain %q, got %q", want, err.Error())
+	}
+
+	if !ranAfterTimeout {
+		t.Error("expected the component after the slow one to still run")
+	}
+}
+
+func TestGroup_Shutdown_AllComponentsSucceed(t *testing.T) {
+	g := NewGroup()
+
+	var order []string
+
+	g.Register("first", time.Second, func(_ context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	g.Register("second", time.Second, func(_ context.Context) error {
+		order = append(order, "second")
+		return nil
+	})
+
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("expected components to run in registration order, got %v", order)
+	}
+}
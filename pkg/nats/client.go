@@ -0,0 +1,176 @@
+package nats
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Handler обрабатывает тело одного сообщения. Чтобы Subscribe мог отличить сообщение,
+// которое стоит повторить, от того, что повторять бессмысленно, обработчик должен
+// оборачивать возвращаемую ошибку через Retryable или Permanent; любая необернутая ошибка
+// по умолчанию считается постоянной
+type Handler func(ctx context.Context, data []byte) error
+
+// workerPoolSize число фоновых воркеров, обрабатывающих входящие сообщения по всем
+// подпискам клиента. NATS доставляет сообщения одной подписки последовательно в рамках
+// своего диспетчера, поэтому обработка (и особенно пауза между повторами при временной
+// ошибке) не должна выполняться прямо в нем - иначе застрявший на ретраях хендлер
+// блокирует доставку всех последующих сообщений, пока клиент не начнет считаться
+// медленным потребителем и NATS не станет сбрасывать для него сообщения
+const workerPoolSize = 16
+
+// Client тонкая обертка над соединением Nats, скрывающая детали конфигурации
+// подключения от остального приложения
+type Client struct {
+	conn *nats.Conn
+
+	// inFlight считает сообщения, которые сейчас обрабатываются (в том числе повторяются),
+	// чтобы Drain мог дождаться их завершения вместо того, чтобы оборвать обработку
+	inFlight sync.WaitGroup
+
+	// jobs очередь задач на обработку одного сообщения, разбирается пулом воркеров,
+	// запущенных в New - см. workerPoolSize
+	jobs chan func()
+}
+
+func New(url string, opts ...nats.Option) (*Client, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{conn: conn, jobs: make(chan func(), workerPoolSize)}
+	for i := 0; i < workerPoolSize; i++ {
+		go c.worker()
+	}
+
+	return c, nil
+}
+
+func (c *Client) worker() {
+	for job := range c.jobs {
+		job()
+	}
+}
+
+// Publish отправляет сообщение в указанный топик
+func (c *Client) Publish(subject string, data []byte) error {
+	return c.conn.Publish(subject, data)
+}
+
+type subOptions struct {
+	retryPolicy    RetryPolicy
+	dlqSubject     string
+	processTimeout time.Duration
+}
+
+// SubOpt настраивает поведение Subscribe
+type SubOpt func(*subOptions)
+
+// WithRetryPolicy задает политику повторов при временных ошибках обработчика
+func WithRetryPolicy(p RetryPolicy) SubOpt {
+	return func(o *subOptions) { o.retryPolicy = p }
+}
+
+// WithDeadLetterSubject задает топик, куда публикуется сообщение вместе с метаданными об
+// ошибке, если обработчик вернул постоянную ошибку или исчерпал все попытки
+func WithDeadLetterSubject(subject string) SubOpt {
+	return func(o *subOptions) { o.dlqSubject = subject }
+}
+
+// WithProcessTimeout ограничивает время, отведенное обработчику на одно сообщение одной
+// попытки - застрявший вызов в базу не должен блокировать подписчика вечно
+func WithProcessTimeout(d time.Duration) SubOpt {
+	return func(o *subOptions) { o.processTimeout = d }
+}
+
+// Subscribe подписывается на subject и для каждого сообщения вызывает h, оборачивая вызов
+// ретраями с экспоненциальной паузой: временные (Retryable) ошибки повторяются согласно
+// retryPolicy, постоянные (Permanent) ошибки и исчерпанные повторы уходят в dead-letter топик,
+// если он задан через WithDeadLetterSubject. Сама обработка (и паузы между повторами) идет в
+// пуле фоновых воркеров клиента, а не в диспетчере подписки NATS, поэтому застрявшее на
+// ретраях сообщение не блокирует доставку следующих - см. workerPoolSize.
+func (c *Client) Subscribe(subject string, h Handler, opts ...SubOpt) error {
+	o := subOptions{
+		retryPolicy:    DefaultRetryPolicy(),
+		processTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	_, err := c.conn.Subscribe(subject, func(msg *nats.Msg) {
+		c.inFlight.Add(1)
+		c.jobs <- func() {
+			defer c.inFlight.Done()
+			c.process(subject, msg.Data, h, o)
+		}
+	})
+	return err
+}
+
+func (c *Client) process(subject string, data []byte, h Handler, o subOptions) {
+	firstSeen := time.Now()
+
+	var lastErr error
+	for attempt := 1; attempt <= o.retryPolicy.MaxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), o.processTimeout)
+		lastErr = h(ctx, data)
+		cancel()
+
+		if lastErr == nil {
+			return
+		}
+
+		if IsPermanent(lastErr) {
+			break
+		}
+
+		if attempt == o.retryPolicy.MaxAttempts {
+			break
+		}
+
+		time.Sleep(o.retryPolicy.backoff(attempt))
+	}
+
+	log.Printf("nats: giving up on subject %q after error: %s", subject, lastErr)
+
+	if o.dlqSubject == "" {
+		return
+	}
+
+	if err := c.publishDeadLetter(o.dlqSubject, subject, data, lastErr, o.retryPolicy.MaxAttempts, firstSeen); err != nil {
+		log.Printf("nats: failed to publish dead letter for subject %q: %s", subject, err)
+	}
+}
+
+// Drain перестает принимать новые сообщения по всем подпискам и ждет, пока завершится
+// обработка уже принятых (включая их повторы), либо пока не истечет ctx
+func (c *Client) Drain(ctx context.Context) error {
+	if err := c.conn.Drain(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close закрывает соединение с Nats
+func (c *Client) Close() error {
+	c.conn.Close()
+	return nil
+}
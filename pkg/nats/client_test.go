@@ -0,0 +1,224 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func startEmbeddedServer(t *testing.T) *natsserver.Server {
+	t.Helper()
+
+	srv, err := natsserver.NewServer(&natsserver.Options{Host: "127.0.0.1", Port: -1})
+	if err != nil {
+		t.Fatalf("failed to create embedded nats server: %s", err)
+	}
+
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("embedded nats server did not become ready in time")
+	}
+	t.Cleanup(srv.Shutdown)
+
+	return srv
+}
+
+func newTestClient(t *testing.T, srv *natsserver.Server) *Client {
+	t.Helper()
+
+	client, err := New(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("failed to connect to embedded nats server: %s", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+// collect подписывается на subject напрямую через низкоуровневое соединение и отдает каждое
+// полученное сообщение в канал - используется в тестах, чтобы перехватить то, что Subscribe
+// публикует в dead-letter топик
+func collect(t *testing.T, client *Client, subject string) <-chan *nats.Msg {
+	t.Helper()
+
+	ch := make(chan *nats.Msg, 8)
+	_, err := client.conn.Subscribe(subject, func(msg *nats.Msg) { ch <- msg })
+	if err != nil {
+		t.Fatalf("failed to subscribe to %q: %s", subject, err)
+	}
+	return ch
+}
+
+func waitForMsg(t *testing.T, ch <-chan *nats.Msg, timeout time.Duration) *nats.Msg {
+	t.Helper()
+
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for message")
+		return nil
+	}
+}
+
+func assertNoMsg(t *testing.T, ch <-chan *nats.Msg, wait time.Duration) {
+	t.Helper()
+
+	select {
+	case msg := <-ch:
+		t.Fatalf("expected no message, got %q", msg.Data)
+	case <-time.After(wait):
+	}
+}
+
+func fastRetryPolicy(maxAttempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: maxAttempts, Base: time.Millisecond, Max: 5 * time.Millisecond}
+}
+
+func TestSubscribe_RetryableError_RetriesThenDeadLetters(t *testing.T) {
+	srv := startEmbeddedServer(t)
+	client := newTestClient(t, srv)
+
+	dlq := collect(t, client, "orders.dlq")
+
+	var attempts int32
+	policy := fastRetryPolicy(3)
+	before := time.Now()
+
+	err := client.Subscribe("orders", func(ctx context.Context, data []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		return Retryable(errors.New("db unavailable"))
+	}, WithRetryPolicy(policy), WithDeadLetterSubject("orders.dlq"))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	if err := client.Publish("orders", []byte(`{"order_uid":"retry-1"}`)); err != nil {
+		t.Fatalf("failed to publish: %s", err)
+	}
+
+	msg := waitForMsg(t, dlq, time.Second)
+
+	if got := atomic.LoadInt32(&attempts); got != int32(policy.MaxAttempts) {
+		t.Fatalf("expected handler to be called %d times, got %d", policy.MaxAttempts, got)
+	}
+
+	var dl deadLetter
+	if err := json.Unmarshal(msg.Data, &dl); err != nil {
+		t.Fatalf("failed to unmarshal dead letter: %s", err)
+	}
+	if dl.Subject != "orders" {
+		t.Errorf("expected subject %q, got %q", "orders", dl.Subject)
+	}
+	if dl.Attempts != policy.MaxAttempts {
+		t.Errorf("expected attempts %d, got %d", policy.MaxAttempts, dl.Attempts)
+	}
+	if dl.Error == "" {
+		t.Error("expected a non-empty error message")
+	}
+	if dl.FirstSeen.IsZero() || dl.FirstSeen.Before(before) {
+		t.Errorf("expected first_seen to be set to roughly publish time, got %s", dl.FirstSeen)
+	}
+	if string(dl.Payload) != `{"order_uid":"retry-1"}` {
+		t.Errorf("expected original payload to be preserved, got %q", dl.Payload)
+	}
+}
+
+func TestSubscribe_PermanentError_ImmediateDeadLetter(t *testing.T) {
+	srv := startEmbeddedServer(t)
+	client := newTestClient(t, srv)
+
+	dlq := collect(t, client, "orders.dlq")
+
+	var attempts int32
+	policy := fastRetryPolicy(5)
+
+	err := client.Subscribe("orders", func(ctx context.Context, data []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		return Permanent(errors.New("malformed payload"))
+	}, WithRetryPolicy(policy), WithDeadLetterSubject("orders.dlq"))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	if err := client.Publish("orders", []byte(`not json`)); err != nil {
+		t.Fatalf("failed to publish: %s", err)
+	}
+
+	msg := waitForMsg(t, dlq, time.Second)
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Fatalf("expected a permanent error to stop after 1 attempt, got %d", got)
+	}
+
+	var dl deadLetter
+	if err := json.Unmarshal(msg.Data, &dl); err != nil {
+		t.Fatalf("failed to unmarshal dead letter: %s", err)
+	}
+	if dl.Attempts != policy.MaxAttempts {
+		t.Errorf("dead letter should still record the configured max attempts (%d), got %d", policy.MaxAttempts, dl.Attempts)
+	}
+}
+
+func TestSubscribe_Success_NoDeadLetter(t *testing.T) {
+	srv := startEmbeddedServer(t)
+	client := newTestClient(t, srv)
+
+	dlq := collect(t, client, "orders.dlq")
+
+	var attempts int32
+
+	err := client.Subscribe("orders", func(ctx context.Context, data []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		return nil
+	}, WithRetryPolicy(fastRetryPolicy(3)), WithDeadLetterSubject("orders.dlq"))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	if err := client.Publish("orders", []byte(`{"order_uid":"ok-1"}`)); err != nil {
+		t.Fatalf("failed to publish: %s", err)
+	}
+
+	// a handler that succeeds on the first attempt should neither retry nor dead-letter
+	assertNoMsg(t, dlq, 200*time.Millisecond)
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Fatalf("expected exactly 1 attempt on success, got %d", got)
+	}
+}
+
+func TestSubscribe_FlakyHandler_SucceedsBeforeExhaustingRetries(t *testing.T) {
+	srv := startEmbeddedServer(t)
+	client := newTestClient(t, srv)
+
+	dlq := collect(t, client, "orders.dlq")
+
+	var attempts int32
+
+	err := client.Subscribe("orders", func(ctx context.Context, data []byte) error {
+		if n := atomic.AddInt32(&attempts, 1); n < 3 {
+			return Retryable(errors.New("temporary glitch"))
+		}
+		return nil
+	}, WithRetryPolicy(fastRetryPolicy(5)), WithDeadLetterSubject("orders.dlq"))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	if err := client.Publish("orders", []byte(`{"order_uid":"flaky-1"}`)); err != nil {
+		t.Fatalf("failed to publish: %s", err)
+	}
+
+	assertNoMsg(t, dlq, 500*time.Millisecond)
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected the handler to succeed on the 3rd attempt, got %d attempts", got)
+	}
+}
@@ -0,0 +1,33 @@
+package nats
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// deadLetter оборачивает исходный payload сообщения вместе с метаданными о том, почему
+// оно не было обработано, и публикуется в отдельный dead-letter топик
+type deadLetter struct {
+	Subject   string    `json:"subject"`
+	Payload   []byte    `json:"payload"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+func (c *Client) publishDeadLetter(dlqSubject, subject string, payload []byte, cause error, attempts int, firstSeen time.Time) error {
+	dl := deadLetter{
+		Subject:   subject,
+		Payload:   payload,
+		Error:     cause.Error(),
+		Attempts:  attempts,
+		FirstSeen: firstSeen,
+	}
+
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+
+	return c.Publish(dlqSubject, data)
+}
@@ -0,0 +1,42 @@
+package nats
+
+import "errors"
+
+// retryable и permanent оборачивают ошибку обработчика, чтобы Subscribe знал, стоит ли
+// повторять попытку обработки сообщения или сразу отправлять его в dead-letter
+
+type retryableError struct{ err error }
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Retryable помечает ошибку как временную - такую, для которой имеет смысл повторить
+// обработку сообщения (например, недоступность базы данных)
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// Permanent помечает ошибку как окончательную - повторная обработка того же сообщения
+// не поможет (например, сообщение не проходит разбор JSON)
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent возвращает true для ошибок, обернутых Permanent. Любая ошибка, не помеченная
+// явно через Retryable, по умолчанию тоже считается постоянной - так мы не уходим в бесконечные
+// повторы на незнакомых ошибках
+func IsPermanent(err error) bool {
+	var retryable *retryableError
+	return !errors.As(err, &retryable)
+}
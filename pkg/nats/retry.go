@@ -0,0 +1,42 @@
+package nats
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy описывает, сколько раз и с какой паузой повторять обработку сообщения
+// при временной (Retryable) ошибке обработчика
+type RetryPolicy struct {
+	MaxAttempts int
+	Base        time.Duration
+	Max         time.Duration
+	// Jitter доля случайного отклонения от рассчитанной паузы, от 0 до 1
+	Jitter float64
+}
+
+// DefaultRetryPolicy повтор с экспоненциальной паузой от 100мс до 5с, максимум 5 попыток
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		Base:        100 * time.Millisecond,
+		Max:         5 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// backoff возвращает паузу после неудачной попытки номер attempt (нумерация с 1), то есть
+// перед попыткой attempt+1, с учетом джиттера
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Base << uint(attempt-1)
+	if d <= 0 || d > p.Max {
+		d = p.Max
+	}
+
+	if p.Jitter <= 0 {
+		return d
+	}
+
+	delta := float64(d) * p.Jitter
+	return d + time.Duration(rand.Float64()*2*delta-delta)
+}